@@ -0,0 +1,226 @@
+// Package cluster wraps an easyenv.Connection behind a Raft consensus
+// layer so that a set of nodes can share a single logical database while
+// each still keeps its own local SQLite file, in the spirit of rqlite.
+// Writes are serialized through the leader and replicated to followers as
+// Commands, which every node (leader included) applies identically to its
+// own local SQLite file; followers reject writes with ErrNotLeader so
+// callers can redirect to the leader.
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hashicorp/raft"
+	raftboltdb "github.com/hashicorp/raft-boltdb"
+	"github.com/mattn/go-sqlite3"
+
+	easyenv "github.com/FriscPlusPlus/easy.env/pkg/easyenvlib"
+)
+
+// ErrNotLeader is returned by Store.Apply when called on a node that isn't
+// the current Raft leader. LeaderAddr is empty if the cluster has no
+// leader at the moment (e.g. an election is in progress).
+type ErrNotLeader struct {
+	LeaderAddr string
+}
+
+func (e *ErrNotLeader) Error() string {
+	if e.LeaderAddr == "" {
+		return "this node is not the cluster leader and no leader is currently known"
+	}
+	return fmt.Sprintf("this node is not the cluster leader; the leader is at %s", e.LeaderAddr)
+}
+
+// Command is the payload replicated through Raft. Op names one of the
+// EasyEnv write operations that must be serialized through the leader;
+// Args carries whatever that operation needs (a project name and path, a
+// template ID, ...), applied identically by every node's fsm.
+type Command struct {
+	Op   string   `json:"op"`
+	Args []string `json:"args"`
+}
+
+// Store binds a Connection to a Raft group. Every node in a cluster runs
+// its own Store against its own local SQLite file; Raft keeps them in
+// sync by replicating Commands.
+type Store struct {
+	connection *easyenv.Connection
+	raft       *raft.Raft
+	fsm        *fsm
+}
+
+// NewStore creates a Store for connection, listening for Raft traffic on
+// bindAddr and keeping consensus state under dataDir. The Raft node runs
+// from this point on, but it is not a member of any cluster until
+// Bootstrap is called or a leader adds it via AddVoter.
+func NewStore(bindAddr, dataDir string, connection *easyenv.Connection) (*Store, error) {
+	if err := os.MkdirAll(dataDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create raft data dir: %w", err)
+	}
+
+	config := raft.DefaultConfig()
+	config.LocalID = raft.ServerID(bindAddr)
+
+	addr, err := net.ResolveTCPAddr("tcp", bindAddr)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve bind address %q: %w", bindAddr, err)
+	}
+
+	transport, err := raft.NewTCPTransport(bindAddr, addr, 3, 10*time.Second, os.Stderr)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create raft transport: %w", err)
+	}
+
+	snapshots, err := raft.NewFileSnapshotStore(dataDir, 2, os.Stderr)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create raft snapshot store: %w", err)
+	}
+
+	boltStore, err := raftboltdb.NewBoltStore(filepath.Join(dataDir, "raft.db"))
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create raft log/stable store: %w", err)
+	}
+
+	machine := &fsm{connection: connection}
+
+	r, err := raft.NewRaft(config, machine, boltStore, boltStore, snapshots, transport)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to start raft: %w", err)
+	}
+
+	return &Store{connection: connection, raft: r, fsm: machine}, nil
+}
+
+// Bootstrap starts a brand new single-node cluster with this Store as its
+// only (and therefore leader) member. Call this once, on the very first
+// node; every other node should join it instead via AddVoter.
+func (s *Store) Bootstrap(bindAddr string) error {
+	future := s.raft.BootstrapCluster(raft.Configuration{
+		Servers: []raft.Server{
+			{ID: raft.ServerID(bindAddr), Address: raft.ServerAddress(bindAddr)},
+		},
+	})
+	return future.Error()
+}
+
+// AddVoter adds a new node to the cluster. It must be called on the
+// current leader; the joining node dials joinAddr out of band to ask the
+// leader to do so (see JoinCluster).
+func (s *Store) AddVoter(nodeID, addr string) error {
+	if s.raft.State() != raft.Leader {
+		return &ErrNotLeader{LeaderAddr: string(s.raft.Leader())}
+	}
+
+	future := s.raft.AddVoter(raft.ServerID(nodeID), raft.ServerAddress(addr), 0, 0)
+	return future.Error()
+}
+
+// IsLeader reports whether this node currently believes itself to be the
+// Raft leader.
+func (s *Store) IsLeader() bool {
+	return s.raft.State() == raft.Leader
+}
+
+// LeaderAddr returns the address of the current leader, or "" if none is
+// known right now.
+func (s *Store) LeaderAddr() string {
+	return string(s.raft.Leader())
+}
+
+// Apply replicates cmd through Raft and applies it to every node's local
+// database, including this one. It fails with ErrNotLeader if called on a
+// follower.
+func (s *Store) Apply(cmd Command, timeout time.Duration) error {
+	_, err := s.applyCommand(cmd, timeout)
+	return err
+}
+
+// applyCommand is like Apply but also returns whatever fsm.Apply returned
+// for cmd, so higher-level wrappers (AddProject, AddTemplate, ...) can hand
+// the caller back the value the write produced.
+func (s *Store) applyCommand(cmd Command, timeout time.Duration) (interface{}, error) {
+	if !s.IsLeader() {
+		return nil, &ErrNotLeader{LeaderAddr: s.LeaderAddr()}
+	}
+
+	payload, err := json.Marshal(cmd)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal cluster command: %w", err)
+	}
+
+	future := s.raft.Apply(payload, timeout)
+
+	if err := future.Error(); err != nil {
+		return nil, err
+	}
+
+	result := future.Response()
+
+	if err, ok := result.(error); ok && err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Shutdown stops Raft participation for this node.
+func (s *Store) Shutdown() error {
+	return s.raft.Shutdown().Error()
+}
+
+// backupTo copies connection's database to destPath using SQLite's online
+// backup API, so that a Raft snapshot can be taken while the database stays
+// open. The whole database is copied in a single Step(-1) call, which holds
+// a read lock on the source until the copy is done.
+func backupTo(connection *easyenv.Connection, destPath string) error {
+	driver := &sqlite3.SQLiteDriver{}
+	destDB, err := driver.Open(destPath)
+
+	if err != nil {
+		return err
+	}
+	defer destDB.Close()
+
+	conn, err := connection.DB().Conn(context.Background())
+
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn interface{}) error {
+		srcConn, ok := driverConn.(*sqlite3.SQLiteConn)
+
+		if !ok {
+			return fmt.Errorf("connection is not backed by mattn/go-sqlite3")
+		}
+
+		dstConn, ok := destDB.(*sqlite3.SQLiteConn)
+
+		if !ok {
+			return fmt.Errorf("destination is not backed by mattn/go-sqlite3")
+		}
+
+		backup, err := dstConn.Backup("main", srcConn, "main")
+
+		if err != nil {
+			return err
+		}
+		defer backup.Close()
+
+		_, err = backup.Step(-1)
+		return err
+	})
+}
@@ -0,0 +1,145 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hashicorp/raft"
+
+	easyenv "github.com/FriscPlusPlus/easy.env/pkg/easyenvlib"
+)
+
+// fsm applies replicated Commands to a node's local Connection. Every node
+// in a cluster runs its own fsm against its own local SQLite file, kept in
+// sync by Raft.
+type fsm struct {
+	connection *easyenv.Connection
+}
+
+// Apply applies a single replicated Command and, for every op that mutates
+// connection's in-memory projects/templates, immediately persists the
+// result via SaveLocal. Without this, a write command followed by a Raft
+// snapshot and log compaction before the next opSaveDB would drop that
+// command permanently on Restore: Snapshot only copies the SQLite file, not
+// the in-memory maps, so anything not yet flushed to disk doesn't survive.
+func (f *fsm) Apply(log *raft.Log) interface{} {
+	var cmd Command
+
+	if err := json.Unmarshal(log.Data, &cmd); err != nil {
+		return fmt.Errorf("failed to unmarshal cluster command: %w", err)
+	}
+
+	switch cmd.Op {
+	case opAddProject:
+		if len(cmd.Args) != 3 {
+			return fmt.Errorf("%s expects 3 args, got %d", cmd.Op, len(cmd.Args))
+		}
+		project := f.connection.AddProjectLocal(cmd.Args[0], cmd.Args[1], cmd.Args[2])
+		if err := f.connection.SaveLocal(); err != nil {
+			return fmt.Errorf("failed to persist %s: %w", cmd.Op, err)
+		}
+		return project
+
+	case opAddTemplate:
+		if len(cmd.Args) != 2 {
+			return fmt.Errorf("%s expects 2 args, got %d", cmd.Op, len(cmd.Args))
+		}
+		template := f.connection.AddTemplateLocal(cmd.Args[0], cmd.Args[1])
+		if err := f.connection.SaveLocal(); err != nil {
+			return fmt.Errorf("failed to persist %s: %w", cmd.Op, err)
+		}
+		return template
+
+	case opAddTemplateEnvsToProject:
+		if len(cmd.Args) != 2 {
+			return fmt.Errorf("%s expects 2 args, got %d", cmd.Op, len(cmd.Args))
+		}
+		if err := f.connection.AddTemplateEnvsToProjectLocal(cmd.Args[0], cmd.Args[1]); err != nil {
+			return err
+		}
+		return f.connection.SaveLocal()
+
+	case opSaveDB:
+		return f.connection.SaveLocal()
+
+	default:
+		return fmt.Errorf("unknown cluster command %q", cmd.Op)
+	}
+}
+
+// Snapshot takes a point-in-time copy of the local database via SQLite's
+// online backup API, so Raft can install it on a node that has fallen too
+// far behind the log to catch up by replaying entries.
+func (f *fsm) Snapshot() (raft.FSMSnapshot, error) {
+	tmpFile, err := os.CreateTemp("", "easyenv-raft-snapshot-*.db")
+
+	if err != nil {
+		return nil, err
+	}
+	tmpPath := tmpFile.Name()
+	tmpFile.Close()
+	os.Remove(tmpPath)
+
+	if err := backupTo(f.connection, tmpPath); err != nil {
+		return nil, err
+	}
+
+	return &fsmSnapshot{path: tmpPath}, nil
+}
+
+// Restore replaces the local database with the contents of a snapshot
+// produced by Snapshot, then reloads the in-memory projects/templates maps
+// so the follower's view matches the restored data.
+func (f *fsm) Restore(snapshot io.ReadCloser) error {
+	defer snapshot.Close()
+
+	dbPath, err := f.connection.FilePath()
+
+	if err != nil {
+		return fmt.Errorf("cannot restore a raft snapshot onto this connection: %w", err)
+	}
+
+	out, err := os.Create(dbPath)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, snapshot); err != nil {
+		out.Close()
+		return err
+	}
+
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	return f.connection.Reload()
+}
+
+type fsmSnapshot struct {
+	path string
+}
+
+func (s *fsmSnapshot) Persist(sink raft.SnapshotSink) error {
+	file, err := os.Open(s.path)
+
+	if err != nil {
+		sink.Cancel()
+		return err
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(sink, file); err != nil {
+		sink.Cancel()
+		return err
+	}
+
+	return sink.Close()
+}
+
+func (s *fsmSnapshot) Release() {
+	os.Remove(s.path)
+}
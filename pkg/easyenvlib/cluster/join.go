@@ -0,0 +1,126 @@
+package cluster
+
+import (
+	"fmt"
+	"net"
+	"net/rpc"
+	"strconv"
+
+	easyenv "github.com/FriscPlusPlus/easy.env/pkg/easyenvlib"
+)
+
+// JoinRequest is sent by a node asking to join an existing cluster to that
+// cluster's current leader.
+type JoinRequest struct {
+	NodeID string
+	Addr   string
+}
+
+// JoinReply is currently empty; it exists so the RPC signature can grow
+// without breaking wire compatibility.
+type JoinReply struct{}
+
+// joinService is the RPC receiver a leader exposes so joining nodes can ask
+// to be added as voters.
+type joinService struct {
+	store *Store
+}
+
+func (j *joinService) Join(req JoinRequest, reply *JoinReply) error {
+	return j.store.AddVoter(req.NodeID, req.Addr)
+}
+
+// controlAddrFor derives the address a Store listens for join RPCs on from
+// its Raft bind address: the same host, one port up.
+func controlAddrFor(bindAddr string) (string, error) {
+	host, portStr, err := net.SplitHostPort(bindAddr)
+
+	if err != nil {
+		return "", fmt.Errorf("invalid bind address %q: %w", bindAddr, err)
+	}
+
+	port, err := strconv.Atoi(portStr)
+
+	if err != nil {
+		return "", fmt.Errorf("invalid port in bind address %q: %w", bindAddr, err)
+	}
+
+	return net.JoinHostPort(host, strconv.Itoa(port+1)), nil
+}
+
+// serveJoinRPC starts (in the background) the RPC listener other nodes use
+// to ask this Store, once it's the leader, to add them as voters.
+func (s *Store) serveJoinRPC(controlAddr string) error {
+	server := rpc.NewServer()
+
+	if err := server.RegisterName("JoinService", &joinService{store: s}); err != nil {
+		return fmt.Errorf("failed to register join RPC service: %w", err)
+	}
+
+	listener, err := net.Listen("tcp", controlAddr)
+
+	if err != nil {
+		return fmt.Errorf("failed to listen for join requests on %q: %w", controlAddr, err)
+	}
+
+	go server.Accept(listener)
+
+	return nil
+}
+
+// JoinCluster brings up a Store for connection listening on bindAddr, then
+// either bootstraps a brand new single-node cluster (joinAddr == "") or
+// asks the node at joinAddr to add this one as a voter.
+//
+// This takes and returns more than the package-level "easy.JoinCluster
+// (bindAddr, joinAddr, dataDir) error" / "easy.LeaderAddr() string" shape
+// originally proposed: cluster already imports easyenv to build Command's
+// payloads against *easyenv.Connection, so a JoinCluster living in package
+// easyenv would need to import cluster right back, an import cycle. The
+// Connection parameter and the returned *Store (which is what LeaderAddr
+// lives on, since a process could in principle join more than one cluster)
+// are the least-worst way to expose this without that cycle or a hidden
+// package-level global holding "the" connection/store.
+func JoinCluster(bindAddr, joinAddr, dataDir string, connection *easyenv.Connection) (*Store, error) {
+	store, err := NewStore(bindAddr, dataDir, connection)
+
+	if err != nil {
+		return nil, err
+	}
+
+	controlAddr, err := controlAddrFor(bindAddr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := store.serveJoinRPC(controlAddr); err != nil {
+		return nil, err
+	}
+
+	if joinAddr == "" {
+		if err := store.Bootstrap(bindAddr); err != nil {
+			return nil, err
+		}
+		return store, nil
+	}
+
+	joinControlAddr, err := controlAddrFor(joinAddr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := rpc.Dial("tcp", joinControlAddr)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach cluster at %q: %w", joinAddr, err)
+	}
+	defer client.Close()
+
+	if err := client.Call("JoinService.Join", JoinRequest{NodeID: bindAddr, Addr: bindAddr}, &JoinReply{}); err != nil {
+		return nil, fmt.Errorf("leader at %q rejected join request: %w", joinAddr, err)
+	}
+
+	return store, nil
+}
@@ -0,0 +1,99 @@
+package cluster
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	easyenv "github.com/FriscPlusPlus/easy.env/pkg/easyenvlib"
+)
+
+// Op names the EasyEnv write operations a Store knows how to replicate.
+const (
+	opAddProject               = "AddProject"
+	opAddTemplate              = "AddTemplate"
+	opAddTemplateEnvsToProject = "AddTemplateEnvsToProject"
+	opSaveDB                   = "SaveDB"
+)
+
+// defaultApplyTimeout bounds how long a write waits for Raft to commit it.
+const defaultApplyTimeout = 10 * time.Second
+
+// newCommandID generates the ID for a replicated create command. It must be
+// called once by the leader and shipped in Command.Args rather than left
+// for each node's fsm to generate independently: every node applies the
+// same command, and if each derived its own ID, replicas would diverge.
+func newCommandID() (string, error) {
+	buf := make([]byte, 16)
+
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate command id: %w", err)
+	}
+
+	return hex.EncodeToString(buf), nil
+}
+
+// AddProject is the clustered equivalent of EasyEnv.AddProject: it
+// replicates the creation through Raft so every node ends up with the same
+// project under the same ID, and fails with ErrNotLeader when called on a
+// follower.
+func (s *Store) AddProject(projectName, path string) (*easyenv.Project, error) {
+	id, err := newCommandID()
+
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.applyCommand(Command{Op: opAddProject, Args: []string{id, projectName, path}}, defaultApplyTimeout)
+
+	if err != nil {
+		return nil, err
+	}
+
+	project, ok := result.(*easyenv.Project)
+
+	if !ok {
+		return nil, fmt.Errorf("cluster command %q returned an unexpected result", opAddProject)
+	}
+
+	return project, nil
+}
+
+// AddTemplate is the clustered equivalent of EasyEnv.AddTemplate.
+func (s *Store) AddTemplate(templateName string) (*easyenv.Template, error) {
+	id, err := newCommandID()
+
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.applyCommand(Command{Op: opAddTemplate, Args: []string{id, templateName}}, defaultApplyTimeout)
+
+	if err != nil {
+		return nil, err
+	}
+
+	template, ok := result.(*easyenv.Template)
+
+	if !ok {
+		return nil, fmt.Errorf("cluster command %q returned an unexpected result", opAddTemplate)
+	}
+
+	return template, nil
+}
+
+// AddTemplateEnvsToProject is the clustered equivalent of
+// EasyEnv.AddTemplateEnvsToProject.
+func (s *Store) AddTemplateEnvsToProject(templateID, projectID string) error {
+	_, err := s.applyCommand(Command{Op: opAddTemplateEnvsToProject, Args: []string{templateID, projectID}}, defaultApplyTimeout)
+	return err
+}
+
+// SaveDB is the clustered equivalent of EasyEnv.SaveDB: it persists every
+// node's local database and .env files via Raft, so a partial write on one
+// node can't leave the cluster in a diverged state.
+func (s *Store) SaveDB() error {
+	_, err := s.applyCommand(Command{Op: opSaveDB}, defaultApplyTimeout)
+	return err
+}
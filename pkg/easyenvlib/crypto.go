@@ -0,0 +1,124 @@
+package easyenv
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Crypto encrypts and decrypts secret environment values before they are
+// persisted to (or read from) the database. KeyID identifies which key a
+// given ciphertext was produced with, so a DB can be re-keyed over time
+// without losing the ability to decrypt older values.
+type Crypto interface {
+	Encrypt(plaintext []byte) (ciphertext []byte, err error)
+	Decrypt(ciphertext []byte) ([]byte, error)
+	KeyID() string
+}
+
+// SetCrypto registers the Crypto implementation connection uses to encrypt
+// and decrypt values added via Project.AddSecret. It must be called before
+// any secret values are read or written on that connection. Crypto is scoped
+// per-Connection (rather than process-global) so two open connections that
+// happen to share a project ID can't read or overwrite each other's secret
+// envelopes with the wrong key.
+func SetCrypto(connection *Connection, c Crypto) {
+	connection.crypto = c
+}
+
+const scryptSaltSize = 16
+const gcmNonceSize = 12
+
+// AESGCMCrypto is the default Crypto implementation. It derives a 256-bit
+// key from a passphrase using scrypt, with a random salt persisted once per
+// database in the db_meta table.
+type AESGCMCrypto struct {
+	keyID string
+	key   []byte
+}
+
+// NewAESGCMCrypto derives an AES-256 key from passphrase and salt using
+// scrypt. salt should be generated once per database with NewScryptSalt and
+// stored in db_meta so the same key can be re-derived later.
+func NewAESGCMCrypto(keyID string, passphrase, salt []byte) (*AESGCMCrypto, error) {
+	key, err := scrypt.Key(passphrase, salt, 1<<15, 8, 1, 32)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive encryption key: %w", err)
+	}
+
+	return &AESGCMCrypto{keyID: keyID, key: key}, nil
+}
+
+// NewScryptSalt generates a random salt suitable for NewAESGCMCrypto.
+func NewScryptSalt() ([]byte, error) {
+	salt := make([]byte, scryptSaltSize)
+
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	return salt, nil
+}
+
+func (c *AESGCMCrypto) KeyID() string {
+	return c.keyID
+}
+
+func (c *AESGCMCrypto) Encrypt(plaintext []byte) ([]byte, error) {
+	gcm, err := c.newGCM()
+
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcmNonceSize)
+
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (c *AESGCMCrypto) Decrypt(ciphertext []byte) ([]byte, error) {
+	gcm, err := c.newGCM()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < gcmNonceSize {
+		return nil, fmt.Errorf("ciphertext too short to contain a nonce")
+	}
+
+	nonce, sealed := ciphertext[:gcmNonceSize], ciphertext[gcmNonceSize:]
+
+	plaintext, err := gcm.Open(nil, nonce, sealed, nil)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt value: %w", err)
+	}
+
+	return plaintext, nil
+}
+
+func (c *AESGCMCrypto) newGCM() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(c.key)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM mode: %w", err)
+	}
+
+	return gcm, nil
+}
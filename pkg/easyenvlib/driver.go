@@ -0,0 +1,169 @@
+package easyenv
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Driver abstracts the persistence backend a Connection talks to, so
+// EasyEnv isn't hard-wired to SQLite. Name identifies the driver for
+// error messages, Placeholder lets call sites build backend-specific
+// parameter markers ("?" for SQLite, "$1" for Postgres, ...), and
+// TableExistsSQL returns a one-bind-parameter query (the table name) that
+// returns a row iff that table exists, since SQLite and Postgres expose
+// that information through different catalog tables.
+type Driver interface {
+	Open(dataSource string) (*sql.DB, error)
+	Name() string
+	Placeholder(n int) string
+	Bootstrap(db *sql.DB) error
+	TableExistsSQL() string
+}
+
+var drivers = map[string]Driver{}
+
+// RegisterDriver adds a Driver to the registry under name, so it can be
+// selected by a "name://..." DSN passed to Load.
+func RegisterDriver(name string, d Driver) {
+	drivers[name] = d
+}
+
+func init() {
+	RegisterDriver("sqlite3", &sqliteDriver{})
+	RegisterDriver("memory", &memoryDriver{})
+
+	// The postgres driver is intentionally NOT registered by default; see
+	// RegisterPostgresDriver.
+}
+
+// errPostgresNotReady explains why RegisterPostgresDriver refuses to
+// register the driver: createTables, saveDataInDB, selectProjects and
+// selectTemplates (and some migrations' Up funcs, which only ever see a
+// *sql.Tx and can't ask a Driver for portable DDL) still contain
+// SQLite-specific SQL that hasn't been routed through
+// Placeholder/TableExistsSQL yet. Postgres support is deferred, not
+// delivered, until that conversion lands.
+var errPostgresNotReady = errors.New("postgres driver is not ready to use: createTables/saveDataInDB/selectProjects/selectTemplates still hard-code SQLite SQL; this request is deferred, not implemented")
+
+// RegisterPostgresDriver would opt a binary into "postgres://" DSNs, but
+// currently always returns errPostgresNotReady: registering the driver
+// while the core query functions still hard-code SQLite's "?" placeholder
+// and DDL would let writes through a postgres:// connection fail or corrupt
+// data rather than working as advertised. Call this (and check its error)
+// once that conversion work lands, instead of registering postgresDriver
+// directly.
+func RegisterPostgresDriver() error {
+	return errPostgresNotReady
+}
+
+// splitDSN splits a URL-style DSN ("sqlite:///path/to.db", "postgres://...",
+// "memory://name") into the registered driver name and the data source
+// string that driver's Open expects. A dbPath with no "scheme://" prefix is
+// treated as a plain SQLite file path, for backwards compatibility with
+// callers that pass a bare path to Load.
+func splitDSN(dbPath string) (driverName, dataSource string) {
+	scheme, rest, found := strings.Cut(dbPath, "://")
+
+	if !found {
+		return "sqlite3", dbPath
+	}
+
+	switch scheme {
+	case "sqlite":
+		return "sqlite3", rest
+	case "postgres", "postgresql":
+		return "postgres", dbPath
+	default:
+		return scheme, rest
+	}
+}
+
+/*
+	SQLite driver (default, current behavior)
+*/
+
+type sqliteDriver struct{}
+
+func (d *sqliteDriver) Name() string { return "sqlite3" }
+
+func (d *sqliteDriver) Open(dataSource string) (*sql.DB, error) {
+	return sql.Open("sqlite3", dataSource)
+}
+
+func (d *sqliteDriver) Placeholder(n int) string { return "?" }
+
+func (d *sqliteDriver) Bootstrap(db *sql.DB) error { return nil }
+
+func (d *sqliteDriver) TableExistsSQL() string {
+	return "SELECT name FROM sqlite_master WHERE type = 'table' AND name = ?"
+}
+
+/*
+	Postgres driver, via github.com/jackc/pgx/v5/stdlib
+*/
+
+type postgresDriver struct{}
+
+func (d *postgresDriver) Name() string { return "postgres" }
+
+func (d *postgresDriver) Open(dataSource string) (*sql.DB, error) {
+	return sql.Open("pgx", dataSource)
+}
+
+func (d *postgresDriver) Placeholder(n int) string { return fmt.Sprintf("$%d", n) }
+
+func (d *postgresDriver) Bootstrap(db *sql.DB) error { return nil }
+
+func (d *postgresDriver) TableExistsSQL() string {
+	return "SELECT table_name FROM information_schema.tables WHERE table_name = $1"
+}
+
+/*
+	In-memory driver, useful for tests
+*/
+
+type memoryDriver struct{}
+
+func (d *memoryDriver) Name() string { return "memory" }
+
+func (d *memoryDriver) Open(dataSource string) (*sql.DB, error) {
+	// Named, shared in-memory SQLite databases: every connection using the
+	// same name shares the same data for the lifetime of the process.
+	return sql.Open("sqlite3", fmt.Sprintf("file:%s?mode=memory&cache=shared", dataSource))
+}
+
+func (d *memoryDriver) Placeholder(n int) string { return "?" }
+
+func (d *memoryDriver) Bootstrap(db *sql.DB) error { return nil }
+
+func (d *memoryDriver) TableExistsSQL() string {
+	return "SELECT name FROM sqlite_master WHERE type = 'table' AND name = ?"
+}
+
+// Placeholder returns the n-th bind parameter marker for the backend this
+// connection was opened against ("?" for SQLite/memory, "$1"-style for
+// Postgres). SQL built by createTables, saveDataInDB, selectProjects and
+// selectTemplates should be routed through this instead of hard-coding "?".
+func (connection *Connection) Placeholder(n int) string {
+	return connection.driver.Placeholder(n)
+}
+
+// FilePath returns the on-disk path to connection's SQLite database file,
+// resolving the "sqlite://" scheme (or a bare, scheme-less path) the same
+// way splitDSN does. It errors for connections that aren't backed by a real
+// SQLite file, such as postgres or memory: operations like restoring a
+// Raft snapshot only make sense against an actual file on disk.
+func (connection *Connection) FilePath() (string, error) {
+	driverName, dataSource := splitDSN(connection.dbPath)
+
+	if driverName != "sqlite3" {
+		return "", fmt.Errorf("connection %q is not backed by a SQLite file (driver %q)", connection.dbPath, driverName)
+	}
+
+	return dataSource, nil
+}
@@ -1,6 +1,7 @@
 package easyenv
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"os"
@@ -12,14 +13,19 @@ import (
 type EasyEnv struct {
 	connections       []*Connection
 	currentConnection *Connection
+	syncStrategy      SyncStrategy // how SaveDB reconciles the DB with on-disk .env files; see SetSyncStrategy
+	skipSecretsInFile bool         // whether SaveAllProjectEnvironmentsToFile omits AddSecret values; see SetSkipSecretsInFile
 }
 
 type Connection struct {
 	Name      string               // db file name
-	dbPath    string               // db absolute path (acts like an id too)
+	dbPath    string               // db DSN as passed to Load (acts like an id too)
 	db        *sql.DB              // db instance
+	driver    Driver               // backend the db instance was opened through
 	projects  map[string]*Project  // projects and the associated env data
 	templates map[string]*Template // templates of all the envs
+	crypto    Crypto               // encrypts/decrypts values added via Project.AddSecret; see SetCrypto
+	secrets   map[string]map[string]*secretEnvelope // AddSecret'd keys, by project ID then env key; see secrets.go
 }
 
 func NewEasyEnv() *EasyEnv {
@@ -62,8 +68,46 @@ func (easy *EasyEnv) isCurrentDBSet() error {
 	return nil
 }
 
+// Load opens (or re-opens) the database identified by dbPath and brings it
+// up to the current schema version. dbPath is a URL-style DSN such as
+// "sqlite:///path/to.db" or "memory://name"; a bare filesystem path (no
+// "scheme://" prefix) is treated as a SQLite file path for backwards
+// compatibility. No driver is registered for "postgres://" DSNs by default
+// (see RegisterPostgresDriver), so Load returns an error for them unless the
+// caller has registered one with RegisterDriver. See LoadContext to pass a
+// context.Context, e.g. to bound how long migrations are allowed to run for.
 func (easy *EasyEnv) Load(dbPath string) (*Connection, error) {
-	db, err := sql.Open("sqlite3", dbPath)
+	return easy.LoadContext(context.Background(), dbPath)
+}
+
+func (easy *EasyEnv) LoadContext(ctx context.Context, dbPath string) (*Connection, error) {
+	connection, err := easy.openConnection(ctx, dbPath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := connection.runMigrations(ctx); err != nil {
+		return nil, err
+	}
+
+	return easy.currentConnection, nil
+}
+
+// openConnection opens dbPath and registers it as easy's current connection,
+// without running migrations. LoadContext runs migrations right after
+// opening; CreateNewDBContext needs createTables to run first instead, since
+// migration 1 expects the baseline "projects" table to already exist.
+func (easy *EasyEnv) openConnection(ctx context.Context, dbPath string) (*Connection, error) {
+	driverName, dataSource := splitDSN(dbPath)
+
+	driver, ok := drivers[driverName]
+
+	if !ok {
+		return nil, fmt.Errorf("no driver registered for %q. Please call easyenv.RegisterDriver first", driverName)
+	}
+
+	db, err := driver.Open(dataSource)
 
 	connection := new(Connection)
 
@@ -71,19 +115,28 @@ func (easy *EasyEnv) Load(dbPath string) (*Connection, error) {
 		return nil, err
 	}
 
+	if err := db.PingContext(ctx); err != nil {
+		return nil, err
+	}
+
 	connection.dbPath = dbPath
 	connection.db = db
-	splittedPath := strings.Split(dbPath, string(os.PathSeparator))
+	connection.driver = driver
+	splittedPath := strings.Split(dataSource, string(os.PathSeparator))
 	connection.Name = splittedPath[len(splittedPath)-1]
 	connection.projects = make(map[string]*Project)
 	connection.templates = make(map[string]*Template)
 
 	easy.connections = append(easy.connections, connection)
 	easy.currentConnection = connection
-	return easy.currentConnection, nil
+	return connection, nil
 }
 
 func (easy *EasyEnv) Open(dbPath string) (*Connection, error) {
+	return easy.OpenContext(context.Background(), dbPath)
+}
+
+func (easy *EasyEnv) OpenContext(ctx context.Context, dbPath string) (*Connection, error) {
 	connection, err := easy.getConnectionBydbPath(dbPath)
 
 	if err != nil {
@@ -95,6 +148,10 @@ func (easy *EasyEnv) Open(dbPath string) (*Connection, error) {
 }
 
 func (easy *EasyEnv) CloseDB(dbPath string) error {
+	return easy.CloseDBContext(context.Background(), dbPath)
+}
+
+func (easy *EasyEnv) CloseDBContext(ctx context.Context, dbPath string) error {
 	connection, err := easy.getConnectionBydbPath(dbPath)
 
 	if err != nil {
@@ -117,13 +174,33 @@ func (easy *EasyEnv) CloseDB(dbPath string) error {
 }
 
 func (easy *EasyEnv) CreateNewDB(dbPath string) (*Connection, error) {
-	connection, err := easy.Load(dbPath)
+	return easy.CreateNewDBContext(context.Background(), dbPath)
+}
+
+func (easy *EasyEnv) CreateNewDBContext(ctx context.Context, dbPath string) (*Connection, error) {
+	connection, err := easy.openConnection(ctx, dbPath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = connection.driver.Bootstrap(connection.db)
 
 	if err != nil {
 		return nil, err
 	}
 
-	err = createTables(connection)
+	// createTables must run before runMigrations: migration 1 expects the
+	// baseline "projects" table to already exist (see ErrDatabaseNotInitialized
+	// in migration.go), and runMigrations' legacy-stamp path recognizes that
+	// table and stamps schema version 1 without re-running migration 1's Up.
+	err = createTables(ctx, connection)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = connection.runMigrations(ctx)
 
 	if err != nil {
 		return nil, err
@@ -133,6 +210,10 @@ func (easy *EasyEnv) CreateNewDB(dbPath string) (*Connection, error) {
 }
 
 func (easy *EasyEnv) SaveDB() error {
+	return easy.SaveDBContext(context.Background())
+}
+
+func (easy *EasyEnv) SaveDBContext(ctx context.Context) error {
 
 	err := easy.isCurrentDBSet()
 
@@ -140,7 +221,19 @@ func (easy *EasyEnv) SaveDB() error {
 		return err
 	}
 
-	err = saveDataInDB(easy.currentConnection)
+	err = easy.SyncContext(ctx, easy.syncStrategy)
+
+	if err != nil {
+		return err
+	}
+
+	err = saveDataInDB(ctx, easy.currentConnection)
+
+	if err != nil {
+		return err
+	}
+
+	err = saveSecretsToDB(ctx, easy.currentConnection)
 
 	if err != nil {
 		return err
@@ -152,13 +245,13 @@ func (easy *EasyEnv) SaveDB() error {
 		return err
 	}
 
-	easy.currentConnection.projects, err = easy.LoadProjects()
+	easy.currentConnection.projects, err = easy.LoadProjectsContext(ctx)
 
 	if err != nil {
 		return err
 	}
 
-	easy.currentConnection.templates, err = easy.LoadTemplates()
+	easy.currentConnection.templates, err = easy.LoadTemplatesContext(ctx)
 
 	if err != nil {
 		return err
@@ -167,6 +260,15 @@ func (easy *EasyEnv) SaveDB() error {
 	return nil
 }
 
+// SetSkipSecretsInFile controls whether SaveAllProjectEnvironmentsToFile
+// writes values added via Project.AddSecret into a project's .env file. The
+// default, false, preserves EasyEnv's original behavior of always flushing
+// every in-memory value to disk; set it to true to keep secret values in the
+// (encrypted) database only.
+func (easy *EasyEnv) SetSkipSecretsInFile(skip bool) {
+	easy.skipSecretsInFile = skip
+}
+
 func (easy *EasyEnv) SaveAllProjectEnvironmentsToFile() error {
 
 	err := easy.isCurrentDBSet()
@@ -176,7 +278,11 @@ func (easy *EasyEnv) SaveAllProjectEnvironmentsToFile() error {
 	}
 
 	for _, project := range easy.currentConnection.projects {
-		err = project.SaveEnvironmentsToFile()
+		if easy.skipSecretsInFile {
+			err = saveNonSecretEnvironmentsToFile(easy.currentConnection, project)
+		} else {
+			err = project.SaveEnvironmentsToFile()
+		}
 
 		if err != nil {
 			return err
@@ -219,7 +325,11 @@ func (easy *EasyEnv) AddTemplate(templateName string) (*Template, error) {
 */
 
 func (easy *EasyEnv) LoadProjects() (map[string]*Project, error) {
-	projects, err := selectProjects(easy.currentConnection)
+	return easy.LoadProjectsContext(context.Background())
+}
+
+func (easy *EasyEnv) LoadProjectsContext(ctx context.Context) (map[string]*Project, error) {
+	projects, err := selectProjects(ctx, easy.currentConnection)
 
 	if err != nil {
 		return nil, err
@@ -236,11 +346,19 @@ func (easy *EasyEnv) LoadProjects() (map[string]*Project, error) {
 		}
 	}
 
+	if err := loadSecretsFromDB(ctx, easy.currentConnection); err != nil {
+		return projects, err
+	}
+
 	return projects, nil
 }
 
 func (easy *EasyEnv) LoadTemplates() (map[string]*Template, error) {
-	templates, err := selectTemplates(easy.currentConnection)
+	return easy.LoadTemplatesContext(context.Background())
+}
+
+func (easy *EasyEnv) LoadTemplatesContext(ctx context.Context) (map[string]*Template, error) {
+	templates, err := selectTemplates(ctx, easy.currentConnection)
 
 	if err != nil {
 		return nil, err
@@ -252,13 +370,17 @@ func (easy *EasyEnv) LoadTemplates() (map[string]*Template, error) {
 }
 
 func (easy *EasyEnv) AddTemplateEnvsToProject(templateID, projectID string) error {
-	project, err := easy.GetProject(projectID)
+	return easy.AddTemplateEnvsToProjectContext(context.Background(), templateID, projectID)
+}
+
+func (easy *EasyEnv) AddTemplateEnvsToProjectContext(ctx context.Context, templateID, projectID string) error {
+	project, err := easy.GetProjectContext(ctx, projectID)
 
 	if err != nil {
 		return err
 	}
 
-	template, err := easy.GetTemplate(templateID)
+	template, err := easy.GetTemplateContext(ctx, templateID)
 
 	if err != nil {
 		return err
@@ -277,7 +399,136 @@ func (easy *EasyEnv) GetDatabases() []*Connection {
 	return easy.connections
 }
 
+// DBPath returns the DSN the connection was opened with, as passed to Load.
+func (connection *Connection) DBPath() string {
+	return connection.dbPath
+}
+
+// DB returns the underlying *sql.DB for the connection. It exists for
+// packages that need direct access to a connection's local database, such
+// as easyenv/cluster copying it with SQLite's online backup API for a Raft
+// snapshot.
+func (connection *Connection) DB() *sql.DB {
+	return connection.db
+}
+
+// AddProjectLocal creates a project and adds it to connection's in-memory
+// map without going through an EasyEnv, so callers that only hold a
+// Connection (such as easyenv/cluster applying a replicated write) can
+// still perform the same mutation AddProject does. id overrides whatever ID
+// NewProject would otherwise generate: a Raft FSM applies the same command
+// on every node, so the ID must be decided once (by the leader) and shipped
+// to followers rather than generated independently on each node.
+func (connection *Connection) AddProjectLocal(id, projectName, path string) *Project {
+	project := NewProject(projectName, path)
+	project.projectID = id
+	connection.projects[project.projectID] = project
+	return project
+}
+
+// AddTemplateLocal is the Connection-level equivalent of AddTemplate. See
+// AddProjectLocal for why id is supplied by the caller rather than
+// generated here.
+func (connection *Connection) AddTemplateLocal(id, templateName string) *Template {
+	template := NewTemplate(templateName)
+	template.templateID = id
+	connection.templates[template.templateID] = template
+	return template
+}
+
+// AddTemplateEnvsToProjectLocal is the Connection-level equivalent of
+// AddTemplateEnvsToProject.
+func (connection *Connection) AddTemplateEnvsToProjectLocal(templateID, projectID string) error {
+	project, ok := connection.projects[projectID]
+
+	if !ok {
+		return fmt.Errorf("no project found with ID %s. Please check the ID and try again", projectID)
+	}
+
+	template, ok := connection.templates[templateID]
+
+	if !ok {
+		return fmt.Errorf("no template found with ID %s. Please verify the ID and try again", templateID)
+	}
+
+	for _, env := range template.GetEnvironments() {
+		project.AddEnvironment(env.GetKey(), env.GetValue())
+	}
+
+	return nil
+}
+
+// SaveLocal is the Connection-level equivalent of EasyEnv.SaveDB: it
+// persists the in-memory projects/templates to the database and to their
+// .env files, then reloads them from disk. See SaveLocalContext to pass a
+// context.Context.
+func (connection *Connection) SaveLocal() error {
+	return connection.SaveLocalContext(context.Background())
+}
+
+func (connection *Connection) SaveLocalContext(ctx context.Context) error {
+	if err := saveDataInDB(ctx, connection); err != nil {
+		return err
+	}
+
+	if err := saveSecretsToDB(ctx, connection); err != nil {
+		return err
+	}
+
+	for _, project := range connection.projects {
+		if err := project.SaveEnvironmentsToFile(); err != nil {
+			return err
+		}
+	}
+
+	return connection.ReloadContext(ctx)
+}
+
+// Reload re-reads projects and templates from the database into memory,
+// discarding whatever this connection currently holds. It's meant for
+// callers that write to a connection's database out of band (e.g. the
+// easyenv/cluster package restoring a Raft snapshot onto disk) and need the
+// in-memory view to catch up afterwards. See ReloadContext to pass a
+// context.Context.
+func (connection *Connection) Reload() error {
+	return connection.ReloadContext(context.Background())
+}
+
+func (connection *Connection) ReloadContext(ctx context.Context) error {
+	projects, err := selectProjects(ctx, connection)
+
+	if err != nil {
+		return err
+	}
+
+	connection.projects = projects
+
+	for _, project := range projects {
+		if err := project.LoadEnvironmentsFromFile(); err != nil {
+			return err
+		}
+	}
+
+	if err := loadSecretsFromDB(ctx, connection); err != nil {
+		return err
+	}
+
+	templates, err := selectTemplates(ctx, connection)
+
+	if err != nil {
+		return err
+	}
+
+	connection.templates = templates
+
+	return nil
+}
+
 func (easy *EasyEnv) GetProject(projectID string) (*Project, error) {
+	return easy.GetProjectContext(context.Background(), projectID)
+}
+
+func (easy *EasyEnv) GetProjectContext(ctx context.Context, projectID string) (*Project, error) {
 
 	err := easy.isCurrentDBSet()
 
@@ -295,6 +546,10 @@ func (easy *EasyEnv) GetProject(projectID string) (*Project, error) {
 }
 
 func (easy *EasyEnv) GetProjects() (map[string]*Project, error) {
+	return easy.GetProjectsContext(context.Background())
+}
+
+func (easy *EasyEnv) GetProjectsContext(ctx context.Context) (map[string]*Project, error) {
 	err := easy.isCurrentDBSet()
 
 	if err != nil {
@@ -305,6 +560,11 @@ func (easy *EasyEnv) GetProjects() (map[string]*Project, error) {
 }
 
 func (easy *EasyEnv) GetTemplate(templateID string) (*Template, error) {
+	return easy.GetTemplateContext(context.Background(), templateID)
+}
+
+func (easy *EasyEnv) GetTemplateContext(ctx context.Context, templateID string) (*Template, error) {
+
 	err := easy.isCurrentDBSet()
 
 	if err != nil {
@@ -321,6 +581,10 @@ func (easy *EasyEnv) GetTemplate(templateID string) (*Template, error) {
 }
 
 func (easy *EasyEnv) GetTemplates() (map[string]*Template, error) {
+	return easy.GetTemplatesContext(context.Background())
+}
+
+func (easy *EasyEnv) GetTemplatesContext(ctx context.Context) (map[string]*Template, error) {
 	err := easy.isCurrentDBSet()
 
 	if err != nil {
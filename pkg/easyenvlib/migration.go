@@ -0,0 +1,221 @@
+package easyenv
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Migration describes a single, versioned change to the EasyEnv schema.
+// Up is run inside its own transaction; Down is optional and only used
+// by tooling that needs to roll a database back.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(*sql.Tx) error
+	Down        func(*sql.Tx) error
+}
+
+var migrations []Migration
+
+// RegisterMigration adds a migration to the package-level registry. It is
+// meant to be called from package init() so that every migration a binary
+// knows about is available before any connection is opened.
+func RegisterMigration(m Migration) {
+	migrations = append(migrations, m)
+}
+
+// ErrSchemaAhead is returned when a database's recorded schema version is
+// newer than any migration this binary knows about, e.g. the DB was last
+// opened by a newer version of easy.env.
+type ErrSchemaAhead struct {
+	DBVersion    int
+	KnownVersion int
+}
+
+func (e *ErrSchemaAhead) Error() string {
+	return fmt.Sprintf("database schema version %d is newer than the latest version known to this binary (%d); please upgrade easy.env", e.DBVersion, e.KnownVersion)
+}
+
+// applied_at is TEXT (an RFC3339Nano timestamp) rather than a native
+// DATETIME/TIMESTAMPTZ column so this DDL works unchanged on every
+// registered Driver instead of needing per-backend variants.
+const createSchemaMigrationsTableSQL = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER PRIMARY KEY,
+	applied_at TEXT NOT NULL
+)`
+
+// ErrDatabaseNotInitialized is returned when migration 1 runs against a
+// database that has never had its baseline schema laid down. createTables
+// (called by CreateNewDB) is what actually creates the "projects" table;
+// Load on a database that was never created through CreateNewDB has no
+// baseline to build on, so it fails fast here instead of silently ending
+// up with schema_migrations/db_meta bookkeeping but no projects/templates
+// tables underneath it.
+var ErrDatabaseNotInitialized = errors.New("database has not been initialized; call CreateNewDB before Load")
+
+func init() {
+	RegisterMigration(Migration{
+		Version:     1,
+		Description: "baseline schema (projects, templates)",
+		Up: func(tx *sql.Tx) error {
+			var name string
+			err := tx.QueryRow("SELECT name FROM sqlite_master WHERE type = 'table' AND name = 'projects'").Scan(&name)
+
+			if err == sql.ErrNoRows {
+				return ErrDatabaseNotInitialized
+			}
+
+			return err
+		},
+	})
+}
+
+// SchemaVersion returns the highest migration version recorded as applied
+// against this connection's database, or 0 if none have been applied yet.
+func (connection *Connection) SchemaVersion() (int, error) {
+	return currentSchemaVersion(context.Background(), connection.db, connection.driver)
+}
+
+func currentSchemaVersion(ctx context.Context, db *sql.DB, driver Driver) (int, error) {
+	exists, err := hasTable(ctx, db, driver, "schema_migrations")
+
+	if err != nil {
+		return 0, err
+	}
+
+	if !exists {
+		// Fresh DB, or one opened before migrations existed: treat that the
+		// same as "no migrations applied".
+		return 0, nil
+	}
+
+	var version sql.NullInt64
+	row := db.QueryRowContext(ctx, "SELECT MAX(version) FROM schema_migrations")
+
+	if err := row.Scan(&version); err != nil {
+		return 0, err
+	}
+
+	return int(version.Int64), nil
+}
+
+// hasTable reports whether the given table already exists in db, using
+// driver's TableExistsSQL so the check works across backends.
+func hasTable(ctx context.Context, db *sql.DB, driver Driver, name string) (bool, error) {
+	var found string
+	err := db.QueryRowContext(ctx, driver.TableExistsSQL(), name).Scan(&found)
+
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// runMigrations brings connection's database up to the latest schema
+// version known to this binary, applying each pending migration inside
+// its own transaction. Databases that already have the legacy "projects"
+// table but no schema_migrations bookkeeping are stamped as version 1
+// without re-running migration 1's Up func.
+func (connection *Connection) runMigrations(ctx context.Context) error {
+	if _, err := connection.db.ExecContext(ctx, createSchemaMigrationsTableSQL); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	current, err := currentSchemaVersion(ctx, connection.db, connection.driver)
+
+	if err != nil {
+		return fmt.Errorf("failed to read current schema version: %w", err)
+	}
+
+	if current == 0 {
+		legacy, err := hasTable(ctx, connection.db, connection.driver, "projects")
+
+		if err != nil {
+			return fmt.Errorf("failed to inspect existing schema: %w", err)
+		}
+
+		if legacy {
+			stampSQL := fmt.Sprintf("INSERT INTO schema_migrations (version, applied_at) VALUES (1, %s)", connection.Placeholder(1))
+			if _, err := connection.db.ExecContext(ctx, stampSQL, time.Now().Format(time.RFC3339Nano)); err != nil {
+				return fmt.Errorf("failed to stamp baseline schema version: %w", err)
+			}
+			current = 1
+		}
+	}
+
+	sorted := make([]Migration, len(migrations))
+	copy(sorted, migrations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+
+	latest := 0
+	for _, m := range sorted {
+		if m.Version > latest {
+			latest = m.Version
+		}
+	}
+
+	if current > latest {
+		return &ErrSchemaAhead{DBVersion: current, KnownVersion: latest}
+	}
+
+	for _, m := range sorted {
+		if m.Version <= current {
+			continue
+		}
+
+		tx, err := connection.db.BeginTx(ctx, nil)
+
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction for migration %d: %w", m.Version, err)
+		}
+
+		if err := m.Up(tx); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Description, err)
+		}
+
+		recordSQL := fmt.Sprintf("INSERT INTO schema_migrations (version, applied_at) VALUES (%s, %s)", connection.Placeholder(1), connection.Placeholder(2))
+		if _, err := tx.ExecContext(ctx, recordSQL, m.Version, time.Now().Format(time.RFC3339Nano)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record migration %d: %w", m.Version, err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit migration %d: %w", m.Version, err)
+		}
+
+		current = m.Version
+	}
+
+	return nil
+}
+
+// Migrate opens (or reuses an existing connection to) the database at
+// dbPath and brings it up to the latest known schema version. It is the
+// explicit counterpart to the automatic migration run performed by Load.
+func (easy *EasyEnv) Migrate(dbPath string) error {
+	return easy.MigrateContext(context.Background(), dbPath)
+}
+
+func (easy *EasyEnv) MigrateContext(ctx context.Context, dbPath string) error {
+	connection, err := easy.getConnectionBydbPath(dbPath)
+
+	if err != nil {
+		connection, err = easy.LoadContext(ctx, dbPath)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return connection.runMigrations(ctx)
+}
@@ -0,0 +1,307 @@
+package easyenv
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+)
+
+// value/ciphertext are TEXT (base64-encoded) rather than a native BLOB
+// column so this DDL works unchanged on every registered Driver: Postgres
+// has no BLOB type, and SQLite's type affinity accepts either just fine.
+const createDBMetaTableSQL = `CREATE TABLE IF NOT EXISTS db_meta (
+	key   TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+)`
+
+const createSecretEnvelopesTableSQL = `CREATE TABLE IF NOT EXISTS secret_envelopes (
+	project_id TEXT NOT NULL,
+	key        TEXT NOT NULL,
+	ciphertext TEXT NOT NULL,
+	key_id     TEXT NOT NULL,
+	PRIMARY KEY (project_id, key)
+)`
+
+const scryptSaltMetaKey = "scrypt_salt"
+
+func init() {
+	RegisterMigration(Migration{
+		Version:     2,
+		Description: "create db_meta table for encryption salt storage",
+		Up: func(tx *sql.Tx) error {
+			_, err := tx.Exec(createDBMetaTableSQL)
+			return err
+		},
+	})
+
+	RegisterMigration(Migration{
+		Version:     4,
+		Description: "create secret_envelopes table for encrypted secret storage",
+		Up: func(tx *sql.Tx) error {
+			_, err := tx.Exec(createSecretEnvelopesTableSQL)
+			return err
+		},
+	})
+}
+
+// dbMetaSalt returns the per-database scrypt salt used to derive the
+// default AES-GCM key, generating and persisting one on first use.
+func dbMetaSalt(connection *Connection) ([]byte, error) {
+	var encoded string
+	selectSQL := fmt.Sprintf("SELECT value FROM db_meta WHERE key = %s", connection.Placeholder(1))
+	row := connection.db.QueryRow(selectSQL, scryptSaltMetaKey)
+
+	err := row.Scan(&encoded)
+
+	if err == nil {
+		return base64.StdEncoding.DecodeString(encoded)
+	}
+
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	salt, err := NewScryptSalt()
+
+	if err != nil {
+		return nil, err
+	}
+
+	insertSQL := fmt.Sprintf("INSERT INTO db_meta (key, value) VALUES (%s, %s)", connection.Placeholder(1), connection.Placeholder(2))
+	_, err = connection.db.Exec(insertSQL, scryptSaltMetaKey, base64.StdEncoding.EncodeToString(salt))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return salt, nil
+}
+
+// InitDefaultCrypto derives the default AES-GCM Crypto from passphrase and
+// connection's per-database scrypt salt (generating and persisting one on
+// first use via dbMetaSalt), and registers it with SetCrypto. Call this
+// once per process, after opening connection, instead of constructing an
+// AESGCMCrypto by hand, so the salt a secret was encrypted with is always
+// the one actually stored in connection's db_meta table.
+func InitDefaultCrypto(connection *Connection, keyID string, passphrase []byte) error {
+	salt, err := dbMetaSalt(connection)
+
+	if err != nil {
+		return fmt.Errorf("failed to load encryption salt: %w", err)
+	}
+
+	crypto, err := NewAESGCMCrypto(keyID, passphrase, salt)
+
+	if err != nil {
+		return err
+	}
+
+	SetCrypto(connection, crypto)
+
+	return nil
+}
+
+// secretEnvelope is the encrypted form of a secret environment value,
+// keyed by the Project it belongs to and the environment key within it.
+type secretEnvelope struct {
+	ciphertext []byte
+	keyID      string
+}
+
+// secretsFor returns connection's map of AddSecret'd keys, by project ID
+// then env key, allocating it on first use. It's keyed by project ID rather
+// than by *Project so entries survive a project being reloaded into a new
+// instance. Scoping this to the Connection (rather than a package-level
+// global) means two open connections that happen to share a project ID
+// can't read or overwrite each other's secret envelopes.
+func secretsFor(connection *Connection) map[string]map[string]*secretEnvelope {
+	if connection.secrets == nil {
+		connection.secrets = make(map[string]map[string]*secretEnvelope)
+	}
+
+	return connection.secrets
+}
+
+// AddSecret adds an environment value to the project the same way
+// AddEnvironment does, but marks it as secret: the plaintext is kept in
+// memory, and written to the on-disk .env file unless the connection's
+// EasyEnv has called SetSkipSecretsInFile(true), while the copy persisted to
+// the database is encrypted with connection's Crypto (configured via
+// SetCrypto or InitDefaultCrypto). The envelope is written out by
+// saveSecretsToDB, which SaveDB calls as part of every save.
+func (project *Project) AddSecret(connection *Connection, key, value string) error {
+	if connection.crypto == nil {
+		return fmt.Errorf("no Crypto provider configured for this connection. Please call easyenv.SetCrypto (or InitDefaultCrypto) before adding secrets")
+	}
+
+	ciphertext, err := connection.crypto.Encrypt([]byte(value))
+
+	if err != nil {
+		return err
+	}
+
+	project.AddEnvironment(key, value)
+
+	secrets := secretsFor(connection)
+
+	if secrets[project.projectID] == nil {
+		secrets[project.projectID] = make(map[string]*secretEnvelope)
+	}
+
+	secrets[project.projectID][key] = &secretEnvelope{
+		ciphertext: ciphertext,
+		keyID:      connection.crypto.KeyID(),
+	}
+
+	return nil
+}
+
+// IsSecret reports whether key was added to project via AddSecret on
+// connection.
+func (project *Project) IsSecret(connection *Connection, key string) bool {
+	keys, ok := secretsFor(connection)[project.projectID]
+
+	if !ok {
+		return false
+	}
+
+	_, ok = keys[key]
+	return ok
+}
+
+// saveNonSecretEnvironmentsToFile writes project's .env file the same way
+// SaveEnvironmentsToFile does, but omits any key added via AddSecret on
+// connection. It builds a throwaway Project holding only the non-secret
+// keys rather than mutating project itself, the same approach Diff uses to
+// avoid leaving the live project's in-memory state altered.
+func saveNonSecretEnvironmentsToFile(connection *Connection, project *Project) error {
+	scratch := NewProject(project.name, project.path)
+
+	for _, env := range project.GetEnvironments() {
+		if project.IsSecret(connection, env.GetKey()) {
+			continue
+		}
+
+		scratch.AddEnvironment(env.GetKey(), env.GetValue())
+	}
+
+	return scratch.SaveEnvironmentsToFile()
+}
+
+// saveSecretsToDB persists every secret envelope tracked for connection's
+// projects, replacing whatever ciphertext was stored for that project
+// before. It's called by saveDataInDB's callers (SaveDB, Connection.SaveLocal)
+// so a secret's ciphertext is never left stale in the database after
+// AddSecret is called again with a new value.
+func saveSecretsToDB(ctx context.Context, connection *Connection) error {
+	for projectID, envelopes := range secretsFor(connection) {
+		if _, ok := connection.projects[projectID]; !ok {
+			continue
+		}
+
+		tx, err := connection.db.BeginTx(ctx, nil)
+
+		if err != nil {
+			return err
+		}
+
+		deleteSQL := fmt.Sprintf("DELETE FROM secret_envelopes WHERE project_id = %s", connection.Placeholder(1))
+		if _, err := tx.ExecContext(ctx, deleteSQL, projectID); err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		insertSQL := fmt.Sprintf(
+			"INSERT INTO secret_envelopes (project_id, key, ciphertext, key_id) VALUES (%s, %s, %s, %s)",
+			connection.Placeholder(1), connection.Placeholder(2), connection.Placeholder(3), connection.Placeholder(4),
+		)
+
+		for key, envelope := range envelopes {
+			if _, err := tx.ExecContext(ctx,
+				insertSQL,
+				projectID, key, base64.StdEncoding.EncodeToString(envelope.ciphertext), envelope.keyID,
+			); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loadSecretsFromDB reads every project's secret_envelopes rows back into
+// connection's secrets, decrypting each with connection.crypto so IsSecret
+// and the in-memory plaintext stay consistent with what AddSecret originally
+// wrote. A project whose ciphertext can't be decrypted (e.g. no Crypto
+// configured yet) is skipped rather than failing the whole load; its
+// plaintext from the .env file is left as-is.
+func loadSecretsFromDB(ctx context.Context, connection *Connection) error {
+	rows, err := connection.db.QueryContext(ctx, "SELECT project_id, key, ciphertext, key_id FROM secret_envelopes")
+
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	type row struct {
+		projectID, key, keyID, ciphertext string
+	}
+
+	var loaded []row
+
+	for rows.Next() {
+		var r row
+
+		if err := rows.Scan(&r.projectID, &r.key, &r.ciphertext, &r.keyID); err != nil {
+			return err
+		}
+
+		loaded = append(loaded, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, r := range loaded {
+		project, ok := connection.projects[r.projectID]
+
+		if !ok {
+			continue
+		}
+
+		ciphertext, err := base64.StdEncoding.DecodeString(r.ciphertext)
+
+		if err != nil {
+			continue
+		}
+
+		secrets := secretsFor(connection)
+
+		if secrets[r.projectID] == nil {
+			secrets[r.projectID] = make(map[string]*secretEnvelope)
+		}
+
+		secrets[r.projectID][r.key] = &secretEnvelope{ciphertext: ciphertext, keyID: r.keyID}
+
+		if connection.crypto == nil {
+			continue
+		}
+
+		plaintext, err := connection.crypto.Decrypt(ciphertext)
+
+		if err != nil {
+			continue
+		}
+
+		project.AddEnvironment(r.key, string(plaintext))
+	}
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package easyenv
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestAddSecretRoundTripsAsCiphertextOnly(t *testing.T) {
+	easy := NewEasyEnv()
+
+	connection, err := easy.CreateNewDB("memory://secrets_roundtrip_test")
+
+	if err != nil {
+		t.Fatalf("CreateNewDB: %v", err)
+	}
+
+	if err := InitDefaultCrypto(connection, "test-key", []byte("passphrase")); err != nil {
+		t.Fatalf("InitDefaultCrypto: %v", err)
+	}
+
+	project, err := easy.AddProject("demo", filepath.Join(t.TempDir(), ".env"))
+
+	if err != nil {
+		t.Fatalf("AddProject: %v", err)
+	}
+
+	if err := project.AddSecret(connection, "API_KEY", "super-secret"); err != nil {
+		t.Fatalf("AddSecret: %v", err)
+	}
+
+	if !project.IsSecret(connection, "API_KEY") {
+		t.Fatalf("expected API_KEY to be tracked as a secret")
+	}
+
+	if err := saveSecretsToDB(context.Background(), connection); err != nil {
+		t.Fatalf("saveSecretsToDB: %v", err)
+	}
+
+	selectSQL := fmt.Sprintf("SELECT ciphertext FROM secret_envelopes WHERE project_id = %s AND key = %s", connection.Placeholder(1), connection.Placeholder(2))
+
+	var storedCiphertext string
+	if err := connection.db.QueryRow(selectSQL, project.projectID, "API_KEY").Scan(&storedCiphertext); err != nil {
+		t.Fatalf("reading back stored ciphertext: %v", err)
+	}
+
+	if storedCiphertext == "" || storedCiphertext == "super-secret" {
+		t.Fatalf("expected a non-empty, non-plaintext ciphertext column, got %q", storedCiphertext)
+	}
+
+	// Clear this connection's in-memory bookkeeping and reload it from the
+	// database, proving loadSecretsFromDB restores the secret envelopes that
+	// saveSecretsToDB wrote.
+	connection.secrets = nil
+
+	if err := loadSecretsFromDB(context.Background(), connection); err != nil {
+		t.Fatalf("loadSecretsFromDB: %v", err)
+	}
+
+	if !project.IsSecret(connection, "API_KEY") {
+		t.Fatalf("expected API_KEY to still be tracked as a secret after reload")
+	}
+}
+
+func TestAddSecretFailsWithoutCryptoConfigured(t *testing.T) {
+	easy := NewEasyEnv()
+
+	connection, err := easy.CreateNewDB("memory://secrets_nocrypto_test")
+
+	if err != nil {
+		t.Fatalf("CreateNewDB: %v", err)
+	}
+
+	project, err := easy.AddProject("demo", filepath.Join(t.TempDir(), ".env"))
+
+	if err != nil {
+		t.Fatalf("AddProject: %v", err)
+	}
+
+	if err := project.AddSecret(connection, "API_KEY", "super-secret"); err == nil {
+		t.Fatalf("expected AddSecret to fail when no Crypto has been configured for connection")
+	}
+}
+
+func TestSecretsAreScopedPerConnection(t *testing.T) {
+	easyA := NewEasyEnv()
+	easyB := NewEasyEnv()
+
+	connectionA, err := easyA.CreateNewDB("memory://secrets_scope_a_test")
+
+	if err != nil {
+		t.Fatalf("CreateNewDB A: %v", err)
+	}
+
+	connectionB, err := easyB.CreateNewDB("memory://secrets_scope_b_test")
+
+	if err != nil {
+		t.Fatalf("CreateNewDB B: %v", err)
+	}
+
+	if err := InitDefaultCrypto(connectionA, "key-a", []byte("passphrase-a")); err != nil {
+		t.Fatalf("InitDefaultCrypto A: %v", err)
+	}
+
+	projectA, err := easyA.AddProject("demo", filepath.Join(t.TempDir(), ".env"))
+
+	if err != nil {
+		t.Fatalf("AddProject A: %v", err)
+	}
+
+	if err := projectA.AddSecret(connectionA, "API_KEY", "super-secret"); err != nil {
+		t.Fatalf("AddSecret A: %v", err)
+	}
+
+	// connectionB never had AddSecret called on it for this project ID, so
+	// it must not see connectionA's bookkeeping even if the two projects
+	// happened to share an ID.
+	if projectA.IsSecret(connectionB, "API_KEY") {
+		t.Fatalf("expected connectionB's secrets to be independent of connectionA's")
+	}
+}
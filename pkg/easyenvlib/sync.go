@@ -0,0 +1,337 @@
+package easyenv
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const createProjectSyncSnapshotsTableSQL = `CREATE TABLE IF NOT EXISTS project_sync_snapshots (
+	project_id TEXT NOT NULL,
+	key        TEXT NOT NULL,
+	value      TEXT NOT NULL,
+	PRIMARY KEY (project_id, key)
+)`
+
+func init() {
+	RegisterMigration(Migration{
+		Version:     3,
+		Description: "create project_sync_snapshots table for three-way sync",
+		Up: func(tx *sql.Tx) error {
+			_, err := tx.Exec(createProjectSyncSnapshotsTableSQL)
+			return err
+		},
+	})
+}
+
+// EnvChange describes a single environment key that differs between the
+// database and a project's on-disk .env file.
+type EnvChange struct {
+	Key      string
+	OldValue string // value on disk, empty for Added
+	NewValue string // value in the database, empty for Removed
+}
+
+// loadOnDiskEnvs reads project's .env file into a throwaway Project rather
+// than project itself, so nothing ever needs to be restored afterwards, and
+// returns its contents as a map. A missing .env file (e.g. a project just
+// created with AddProject) is reported via ok == false rather than an
+// error, since that's an expected state, not a failure.
+func loadOnDiskEnvs(project *Project) (envs map[string]string, ok bool, err error) {
+	onDisk := NewProject(project.name, project.path)
+
+	if err := onDisk.LoadEnvironmentsFromFile(); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	envs = make(map[string]string)
+
+	for _, env := range onDisk.GetEnvironments() {
+		envs[env.GetKey()] = env.GetValue()
+	}
+
+	return envs, true, nil
+}
+
+// Diff compares project's in-memory (database-backed) environment values
+// against what's currently on disk in its .env file. added holds keys only
+// present in the database, removed holds keys only present on disk, and
+// changed holds keys present in both with differing values. Diff is
+// read-only: it never modifies project, even transiently, so a file-only
+// key can't leak into the database on the next SaveDB.
+func (project *Project) Diff() (added, removed, changed []EnvChange, err error) {
+	dbEnvs := make(map[string]string)
+
+	for _, env := range project.GetEnvironments() {
+		dbEnvs[env.GetKey()] = env.GetValue()
+	}
+
+	fileEnvs, ok, err := loadOnDiskEnvs(project)
+
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if !ok {
+		// No .env file yet: everything in the database is "added".
+		for key, value := range dbEnvs {
+			added = append(added, EnvChange{Key: key, NewValue: value})
+		}
+		return added, nil, nil, nil
+	}
+
+	for key, dbValue := range dbEnvs {
+		fileValue, ok := fileEnvs[key]
+
+		if !ok {
+			added = append(added, EnvChange{Key: key, NewValue: dbValue})
+		} else if fileValue != dbValue {
+			changed = append(changed, EnvChange{Key: key, OldValue: fileValue, NewValue: dbValue})
+		}
+	}
+
+	for key, fileValue := range fileEnvs {
+		if _, ok := dbEnvs[key]; !ok {
+			removed = append(removed, EnvChange{Key: key, OldValue: fileValue})
+		}
+	}
+
+	return added, removed, changed, nil
+}
+
+// SyncStrategy controls how EasyEnv.Sync reconciles the database with
+// on-disk .env files that may have been edited directly between saves.
+type SyncStrategy int
+
+const (
+	// PreferDB keeps the database's values, overwriting the .env file on
+	// the next save. This is the behavior EasyEnv had before Sync existed.
+	PreferDB SyncStrategy = iota
+	// PreferFile keeps whatever is on disk, pulling it back into the
+	// database.
+	PreferFile
+	// Merge takes the union of both sources; the database wins when the
+	// same key differs between the two.
+	Merge
+	// ThreeWay compares both sources against the last synced snapshot to
+	// tell a real conflict (both sides changed the same key since the last
+	// sync) from a one-sided change, returning a ConflictError for the
+	// caller to resolve when it finds one.
+	ThreeWay
+)
+
+// EnvConflict is a single key that ThreeWay sync could not reconcile
+// automatically because both the database and the .env file changed it
+// since the last sync.
+type EnvConflict struct {
+	Key       string
+	DBValue   string
+	FileValue string
+}
+
+// ConflictError is returned by Sync(ThreeWay) when one or more projects
+// have keys that changed on both sides since the last sync.
+type ConflictError struct {
+	ProjectID string
+	Conflicts []EnvConflict
+}
+
+func (e *ConflictError) Error() string {
+	keys := make([]string, len(e.Conflicts))
+
+	for i, c := range e.Conflicts {
+		keys[i] = c.Key
+	}
+
+	return fmt.Sprintf("project %s has unresolved conflicts for key(s): %s", e.ProjectID, strings.Join(keys, ", "))
+}
+
+// SetSyncStrategy configures the SyncStrategy SaveDB uses to reconcile the
+// database with on-disk .env files before saving. The zero value, PreferDB,
+// matches EasyEnv's historical behavior of blindly overwriting the file.
+func (easy *EasyEnv) SetSyncStrategy(strategy SyncStrategy) {
+	easy.syncStrategy = strategy
+}
+
+// Sync reconciles every project's database state with its on-disk .env
+// file according to strategy. See SyncContext to pass a context.Context.
+func (easy *EasyEnv) Sync(strategy SyncStrategy) error {
+	return easy.SyncContext(context.Background(), strategy)
+}
+
+func (easy *EasyEnv) SyncContext(ctx context.Context, strategy SyncStrategy) error {
+	if err := easy.isCurrentDBSet(); err != nil {
+		return err
+	}
+
+	for _, project := range easy.currentConnection.projects {
+		if err := syncProject(ctx, easy.currentConnection, project, strategy); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func syncProject(ctx context.Context, connection *Connection, project *Project, strategy SyncStrategy) error {
+	// Database-only keys (added) need no reconciliation under PreferDB,
+	// Merge or ThreeWay: they'll simply be written out to the file on the
+	// next save. PreferFile is the exception - see below.
+	added, removed, changed, err := project.Diff()
+
+	if err != nil {
+		return err
+	}
+
+	switch strategy {
+	case PreferDB:
+		// Nothing to do: project's in-memory (database) values were never
+		// touched by Diff, and SaveDB will write them out over the file.
+
+	case PreferFile:
+		if len(added) == 0 && len(removed) == 0 && len(changed) == 0 {
+			break
+		}
+
+		// "Prefer file" means the file wins outright, including dropping
+		// keys that only exist in the database - not just patching in the
+		// keys Diff happened to notice differ. AddEnvironment can't remove a
+		// key, so rebuild project's env set from scratch in a new Project
+		// holding exactly what's on disk, and swap it into connection.
+		fileEnvs, ok, err := loadOnDiskEnvs(project)
+
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			rebuilt := NewProject(project.name, project.path)
+			rebuilt.projectID = project.projectID
+
+			for key, value := range fileEnvs {
+				rebuilt.AddEnvironment(key, value)
+			}
+
+			connection.projects[project.projectID] = rebuilt
+			project = rebuilt
+		}
+		// No file on disk yet: there's nothing to prefer, so leave the
+		// database values project already holds untouched.
+
+	case Merge:
+		// DB already wins on conflicting keys; just bring back file-only
+		// keys so the union is preserved.
+		for _, change := range removed {
+			project.AddEnvironment(change.Key, change.OldValue)
+		}
+
+	case ThreeWay:
+		lastSynced, err := loadSyncSnapshot(ctx, connection, project.projectID)
+
+		if err != nil {
+			return err
+		}
+
+		var conflicts []EnvConflict
+
+		for _, change := range changed {
+			baseValue, hadBase := lastSynced[change.Key]
+
+			switch {
+			case !hadBase:
+				// Neither side had this key at the last sync, yet it now
+				// differs between DB and file: both sides added it
+				// independently with different values. That's a genuine
+				// conflict, not something the DB should win by default.
+				conflicts = append(conflicts, EnvConflict{Key: change.Key, DBValue: change.NewValue, FileValue: change.OldValue})
+
+			case baseValue != change.OldValue && baseValue != change.NewValue:
+				conflicts = append(conflicts, EnvConflict{Key: change.Key, DBValue: change.NewValue, FileValue: change.OldValue})
+
+			case baseValue == change.NewValue:
+				// Only the file side moved since the last sync: let it win.
+				project.AddEnvironment(change.Key, change.OldValue)
+
+				// The remaining case, baseValue == change.OldValue, means
+				// only the database side moved: the database wins, which is
+				// already what project holds, so there's nothing to do.
+			}
+		}
+
+		for _, change := range removed {
+			if _, hadBase := lastSynced[change.Key]; hadBase {
+				// Existed at the last sync and only disappeared from the
+				// database: treat the database's deletion as intentional.
+				continue
+			}
+			project.AddEnvironment(change.Key, change.OldValue)
+		}
+
+		if len(conflicts) > 0 {
+			return &ConflictError{ProjectID: project.projectID, Conflicts: conflicts}
+		}
+
+	default:
+		return fmt.Errorf("unknown sync strategy: %d", strategy)
+	}
+
+	return saveSyncSnapshot(ctx, connection, project)
+}
+
+func loadSyncSnapshot(ctx context.Context, connection *Connection, projectID string) (map[string]string, error) {
+	selectSQL := fmt.Sprintf("SELECT key, value FROM project_sync_snapshots WHERE project_id = %s", connection.Placeholder(1))
+	rows, err := connection.db.QueryContext(ctx, selectSQL, projectID)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshot := make(map[string]string)
+
+	for rows.Next() {
+		var key, value string
+
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+
+		snapshot[key] = value
+	}
+
+	return snapshot, rows.Err()
+}
+
+func saveSyncSnapshot(ctx context.Context, connection *Connection, project *Project) error {
+	tx, err := connection.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	deleteSQL := fmt.Sprintf("DELETE FROM project_sync_snapshots WHERE project_id = %s", connection.Placeholder(1))
+	if _, err := tx.ExecContext(ctx, deleteSQL, project.projectID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	insertSQL := fmt.Sprintf(
+		"INSERT INTO project_sync_snapshots (project_id, key, value) VALUES (%s, %s, %s)",
+		connection.Placeholder(1), connection.Placeholder(2), connection.Placeholder(3),
+	)
+
+	for _, env := range project.GetEnvironments() {
+		if _, err := tx.ExecContext(ctx, insertSQL, project.projectID, env.GetKey(), env.GetValue()); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,89 @@
+package easyenv
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSyncPreferFileDropsDatabaseOnlyKeys(t *testing.T) {
+	easy := NewEasyEnv()
+
+	connection, err := easy.CreateNewDB("memory://sync_preferfile_test")
+
+	if err != nil {
+		t.Fatalf("CreateNewDB: %v", err)
+	}
+
+	envPath := filepath.Join(t.TempDir(), ".env")
+
+	if err := os.WriteFile(envPath, []byte("SHARED=from-file\n"), 0o600); err != nil {
+		t.Fatalf("writing .env fixture: %v", err)
+	}
+
+	project, err := easy.AddProject("demo", envPath)
+
+	if err != nil {
+		t.Fatalf("AddProject: %v", err)
+	}
+
+	project.AddEnvironment("SHARED", "from-db")
+	project.AddEnvironment("DB_ONLY", "should not survive PreferFile")
+
+	if err := easy.Sync(PreferFile); err != nil {
+		t.Fatalf("Sync(PreferFile): %v", err)
+	}
+
+	synced := connection.projects[project.projectID]
+	values := make(map[string]string)
+
+	for _, env := range synced.GetEnvironments() {
+		values[env.GetKey()] = env.GetValue()
+	}
+
+	if _, ok := values["DB_ONLY"]; ok {
+		t.Fatalf("PreferFile should have dropped DB_ONLY, a database-only key")
+	}
+
+	if got := values["SHARED"]; got != "from-file" {
+		t.Fatalf("PreferFile should keep the on-disk value for SHARED, got %q", got)
+	}
+}
+
+func TestSyncThreeWayFlagsConcurrentAddAsConflict(t *testing.T) {
+	easy := NewEasyEnv()
+
+	if _, err := easy.CreateNewDB("memory://sync_threeway_test"); err != nil {
+		t.Fatalf("CreateNewDB: %v", err)
+	}
+
+	envPath := filepath.Join(t.TempDir(), ".env")
+
+	project, err := easy.AddProject("demo", envPath)
+
+	if err != nil {
+		t.Fatalf("AddProject: %v", err)
+	}
+
+	// NEW_KEY has no last-synced snapshot entry, and is about to differ
+	// between DB and file: both sides added it independently, which ThreeWay
+	// must report as a conflict rather than silently letting the DB win.
+	if err := os.WriteFile(envPath, []byte("NEW_KEY=from-file\n"), 0o600); err != nil {
+		t.Fatalf("writing .env fixture: %v", err)
+	}
+
+	project.AddEnvironment("NEW_KEY", "from-db")
+
+	err = easy.Sync(ThreeWay)
+
+	var conflictErr *ConflictError
+
+	if !errors.As(err, &conflictErr) {
+		t.Fatalf("expected a *ConflictError for a key added on both sides, got %v", err)
+	}
+
+	if len(conflictErr.Conflicts) != 1 || conflictErr.Conflicts[0].Key != "NEW_KEY" {
+		t.Fatalf("expected exactly one conflict for NEW_KEY, got %+v", conflictErr.Conflicts)
+	}
+}